@@ -0,0 +1,162 @@
+// Copyright 2015 Matthew Holt and The Caddy Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package push
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+)
+
+// cacheDigest is a decoded Golomb-coded set (GCS), the compact,
+// probabilistic structure used by the Cache-Digest request header to
+// tell a server which responses a client already has cached. See the
+// (now-expired) draft-ietf-httpbis-cache-digest.
+type cacheDigest struct {
+	capacity uint64
+	entries  []uint64
+}
+
+// parseCacheDigest decodes the value of a Cache-Digest request header.
+//
+// The wire format is a base64url string whose first byte is the
+// Golomb parameter P, followed by a Golomb-Rice coded, delta-sorted
+// list of fingerprints packed into a set of capacity 2^P. The draft
+// never reached consensus on several details of negotiating capacity
+// out of band, so this is a best-effort decoder covering the common
+// case of a single digest describing the requested path's cache.
+func parseCacheDigest(value string) (*cacheDigest, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < 1 {
+		return nil, errors.New("cache digest: empty value")
+	}
+
+	p := raw[0]
+	if p == 0 || p > 63 {
+		return nil, errors.New("cache digest: invalid Golomb parameter")
+	}
+
+	br := &bitReader{data: raw[1:]}
+	digest := &cacheDigest{capacity: 1 << p}
+
+	var sum uint64
+	for {
+		quotient, ok := br.readUnary()
+		if !ok {
+			break
+		}
+		remainder, ok := br.readBits(int(p))
+		if !ok {
+			break
+		}
+		sum += quotient<<p | remainder
+		digest.entries = append(digest.entries, sum)
+	}
+
+	return digest, nil
+}
+
+// has reports whether the digest indicates that the client likely
+// already has a fresh copy of target cached.
+func (d *cacheDigest) has(target string) bool {
+	if d == nil || len(d.entries) == 0 {
+		return false
+	}
+	fp := fingerprint(target) % d.capacity
+	// Entries are sorted in ascending order, so a linear scan up to the
+	// first entry greater than fp is enough; digests are small enough
+	// in practice that pulling in sort.Search isn't worth it.
+	for _, e := range d.entries {
+		if e == fp {
+			return true
+		}
+		if e > fp {
+			break
+		}
+	}
+	return false
+}
+
+// fingerprint derives a 64-bit digest fingerprint for a cache key
+// (typically a URL, optionally combined with an ETag) by hashing it
+// with SHA-256 and XORing the first two 64-bit big-endian words of the
+// digest; the remaining 16 bytes of the digest are not used.
+//
+// NOTE: we don't have an independent, known-good Cache-Digest producer
+// to validate this derivation against, and the draft this header comes
+// from (draft-ietf-httpbis-cache-digest) expired without the fingerprint
+// construction ever being interoperability-tested in the wild. Treat
+// CacheDigest as a best-effort, same-implementation-on-both-ends
+// mechanism rather than one guaranteed to match digests from real
+// browsers, CDNs, or other servers; it will only suppress a push when
+// the digest was produced the same way this function computes it.
+func fingerprint(key string) uint64 {
+	sum := sha256.Sum256([]byte(key))
+	h1 := binary.BigEndian.Uint64(sum[0:8])
+	h2 := binary.BigEndian.Uint64(sum[8:16])
+	return h1 ^ h2
+}
+
+// bitReader reads bits MSB-first out of a byte slice, as required to
+// decode a Golomb-Rice coded set.
+type bitReader struct {
+	data []byte
+	pos  int // bit offset from the start of data
+}
+
+func (r *bitReader) readBit() (uint64, bool) {
+	byteIdx := r.pos / 8
+	if byteIdx >= len(r.data) {
+		return 0, false
+	}
+	bitIdx := 7 - uint(r.pos%8)
+	r.pos++
+	return uint64(r.data[byteIdx]>>bitIdx) & 1, true
+}
+
+// readUnary reads a unary-coded value: a run of 1 bits terminated by a
+// 0 bit (or the end of input).
+func (r *bitReader) readUnary() (uint64, bool) {
+	var n uint64
+	for {
+		bit, ok := r.readBit()
+		if !ok {
+			if n == 0 {
+				return 0, false
+			}
+			return n, true
+		}
+		if bit == 0 {
+			return n, true
+		}
+		n++
+	}
+}
+
+func (r *bitReader) readBits(n int) (uint64, bool) {
+	var v uint64
+	for i := 0; i < n; i++ {
+		bit, ok := r.readBit()
+		if !ok {
+			return 0, false
+		}
+		v = v<<1 | bit
+	}
+	return v, true
+}
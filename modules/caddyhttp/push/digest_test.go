@@ -0,0 +1,138 @@
+// Copyright 2015 Matthew Holt and The Caddy Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package push
+
+import (
+	"encoding/base64"
+	"sort"
+	"testing"
+)
+
+// bitWriter is the encoding-side counterpart of bitReader, used only by
+// tests to hand-construct a valid Golomb-Rice coded set to round-trip
+// through parseCacheDigest and cacheDigest.has.
+type bitWriter struct {
+	buf []byte
+	pos int
+}
+
+func (w *bitWriter) writeBit(bit uint64) {
+	byteIdx := w.pos / 8
+	for byteIdx >= len(w.buf) {
+		w.buf = append(w.buf, 0)
+	}
+	if bit&1 == 1 {
+		w.buf[byteIdx] |= 1 << (7 - uint(w.pos%8))
+	}
+	w.pos++
+}
+
+func (w *bitWriter) writeUnary(q uint64) {
+	for i := uint64(0); i < q; i++ {
+		w.writeBit(1)
+	}
+	w.writeBit(0)
+}
+
+func (w *bitWriter) writeBits(v uint64, n int) {
+	for i := n - 1; i >= 0; i-- {
+		w.writeBit((v >> uint(i)) & 1)
+	}
+}
+
+// encodeCacheDigest builds a base64url Cache-Digest header value out of
+// a set of already-computed fingerprints, mirroring the wire format
+// parseCacheDigest expects: a leading Golomb parameter byte P followed
+// by the sorted fingerprints, delta-encoded with Golomb-Rice coding.
+func encodeCacheDigest(t *testing.T, p uint8, fingerprints []uint64) string {
+	t.Helper()
+
+	sorted := append([]uint64(nil), fingerprints...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	bw := &bitWriter{}
+	var prev uint64
+	for _, fp := range sorted {
+		delta := fp - prev
+		prev = fp
+		bw.writeUnary(delta >> p)
+		bw.writeBits(delta&(1<<p-1), int(p))
+	}
+
+	raw := append([]byte{p}, bw.buf...)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func TestParseCacheDigestRoundTrip(t *testing.T) {
+	const p = 12 // capacity 4096
+	capacity := uint64(1) << p
+
+	cached := []string{"/app.css", "/app.js"}
+	notCached := []string{"/other.js"}
+
+	var fingerprints []uint64
+	for _, target := range cached {
+		fingerprints = append(fingerprints, fingerprint(target)%capacity)
+	}
+
+	value := encodeCacheDigest(t, p, fingerprints)
+
+	digest, err := parseCacheDigest(value)
+	if err != nil {
+		t.Fatalf("parseCacheDigest returned error: %v", err)
+	}
+
+	for _, target := range cached {
+		if !digest.has(target) {
+			t.Errorf("expected digest to report %q as cached", target)
+		}
+	}
+	for _, target := range notCached {
+		if digest.has(target) {
+			t.Errorf("expected digest to report %q as not cached", target)
+		}
+	}
+}
+
+func TestParseCacheDigestErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		value string
+	}{
+		{"not base64", "not-valid-base64!!"},
+		{"empty payload", base64.RawURLEncoding.EncodeToString(nil)},
+		{"invalid P of zero", base64.RawURLEncoding.EncodeToString([]byte{0})},
+		{"invalid P too large", base64.RawURLEncoding.EncodeToString([]byte{64})},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := parseCacheDigest(tc.value); err == nil {
+				t.Errorf("expected an error for %q", tc.value)
+			}
+		})
+	}
+}
+
+func TestCacheDigestHasOnNilOrEmpty(t *testing.T) {
+	var nilDigest *cacheDigest
+	if nilDigest.has("/anything") {
+		t.Error("nil digest should never report a hit")
+	}
+
+	empty := &cacheDigest{capacity: 1 << 10}
+	if empty.has("/anything") {
+		t.Error("empty digest should never report a hit")
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2015 Matthew Holt and The Caddy Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package push
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap"
+)
+
+func init() {
+	caddy.RegisterModule(EarlyHints{})
+}
+
+// EarlyHints is a middleware that announces resources the client will
+// likely need by sending an RFC 8297 103 Early Hints interim response
+// before the next handler in the chain writes the final response. It
+// is meant as a drop-in replacement for the push handler now that HTTP/2
+// Server Push has been deprecated by most browsers: the same Caddyfile
+// configuration semantics (a list of resources to announce) apply, but
+// instead of actually pushing bytes, the client is simply told what to
+// start fetching early.
+type EarlyHints struct {
+	// Resources is the list of resources to announce via Link headers
+	// in the 103 Early Hints response.
+	Resources []EarlyHintResource `json:"resources,omitempty"`
+
+	// MirrorRequestLinks, if true, also announces any Link values that
+	// reverse_proxy has surfaced from an upstream 103 Early Hints
+	// interim response (see upstreamLink1xxVar in handler.go), in
+	// addition to the statically configured Resources. The variable is
+	// read once, before the next handler runs, so only values already
+	// recorded on the request at that point are announced; Link values
+	// that a reverse_proxy later in this same handler chain records are
+	// not included in the 103 response.
+	MirrorRequestLinks bool `json:"mirror_request_links,omitempty"`
+
+	logger *zap.Logger
+}
+
+// CaddyModule returns the Caddy module information.
+func (EarlyHints) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.early_hints",
+		New: func() caddy.Module { return new(EarlyHints) },
+	}
+}
+
+// Provision sets up h.
+func (h *EarlyHints) Provision(ctx caddy.Context) error {
+	h.logger = ctx.Logger(h)
+	return nil
+}
+
+func (h EarlyHints) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
+	// 1xx interim responses aren't meaningful over HTTP/1.0, and trying
+	// to write one would just get buffered until the final response.
+	if !r.ProtoAtLeast(1, 1) {
+		return next.ServeHTTP(w, r)
+	}
+
+	repl := r.Context().Value(caddy.ReplacerCtxKey).(*caddy.Replacer)
+
+	links := make([]string, 0, len(h.Resources))
+	for _, resource := range h.Resources {
+		links = append(links, resource.linkValue(repl))
+	}
+	if h.MirrorRequestLinks {
+		if upstream, ok := caddyhttp.GetVar(r.Context(), upstreamLink1xxVar).([]string); ok {
+			links = append(links, upstream...)
+		}
+	}
+	if len(links) == 0 {
+		return next.ServeHTTP(w, r)
+	}
+
+	h.writeEarlyHints(w, links)
+
+	return next.ServeHTTP(w, r)
+}
+
+// writeEarlyHints writes the given Link values and a 103 status code to
+// w. There's no portable way for Caddy to know in advance whether the
+// client or an intermediary proxy will actually honor an interim
+// response rather than silently dropping it, so this is a best-effort,
+// always-attempt send: on HTTP/1.1 and HTTP/2 it reaches clients that
+// implement RFC 8297, and it's simply ignored by anything that doesn't.
+func (h EarlyHints) writeEarlyHints(w http.ResponseWriter, links []string) {
+	hdr := w.Header()
+	for _, link := range links {
+		hdr.Add("Link", link)
+	}
+	w.WriteHeader(http.StatusEarlyHints)
+}
+
+// EarlyHintResource represents a single resource to announce in a 103
+// Early Hints response.
+type EarlyHintResource struct {
+	// Target is the path (or absolute URL) of the resource.
+	Target string `json:"target,omitempty"`
+
+	// Rel is the link relation type. Default is "preload".
+	Rel string `json:"rel,omitempty"`
+
+	// As is the destination hint for preloaded resources, e.g.
+	// "script", "style", "font", or "image". See the Fetch spec's
+	// request destination for valid values.
+	As string `json:"as,omitempty"`
+}
+
+func (r EarlyHintResource) linkValue(repl *caddy.Replacer) string {
+	rel := r.Rel
+	if rel == "" {
+		rel = "preload"
+	}
+	val := fmt.Sprintf("<%s>; rel=%s", repl.ReplaceAll(r.Target, "."), rel)
+	if r.As != "" {
+		val += fmt.Sprintf("; as=%s", r.As)
+	}
+	return val
+}
+
+// Interface guards
+var (
+	_ caddy.Provisioner           = (*EarlyHints)(nil)
+	_ caddyhttp.MiddlewareHandler = (*EarlyHints)(nil)
+)
@@ -0,0 +1,150 @@
+// Copyright 2015 Matthew Holt and The Caddy Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package push
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap"
+)
+
+func TestEarlyHintsServeHTTPWritesLinkHeaders(t *testing.T) {
+	h := EarlyHints{
+		Resources: []EarlyHintResource{
+			{Target: "/app.css", As: "style"},
+			{Target: "/app.js", Rel: "preconnect"},
+		},
+		logger: zap.NewNop(),
+	}
+
+	req := newPushRequest(t)
+	w := httptest.NewRecorder()
+
+	nextCalled := false
+	next := caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		nextCalled = true
+		return nil
+	})
+
+	if err := h.ServeHTTP(w, req, next); err != nil {
+		t.Fatalf("ServeHTTP returned error: %v", err)
+	}
+
+	if !nextCalled {
+		t.Error("expected next handler to be called")
+	}
+	if w.Code != http.StatusEarlyHints {
+		t.Errorf("expected status %d, got %d", http.StatusEarlyHints, w.Code)
+	}
+
+	links := w.Header()["Link"]
+	want := []string{
+		"</app.css>; rel=preload; as=style",
+		"</app.js>; rel=preconnect",
+	}
+	if len(links) != len(want) {
+		t.Fatalf("expected %d Link headers, got %v", len(want), links)
+	}
+	for i, link := range want {
+		if links[i] != link {
+			t.Errorf("Link header %d = %q, want %q", i, links[i], link)
+		}
+	}
+}
+
+func TestEarlyHintsServeHTTPSkipsOnHTTP10(t *testing.T) {
+	h := EarlyHints{
+		Resources: []EarlyHintResource{{Target: "/app.css"}},
+		logger:    zap.NewNop(),
+	}
+
+	req := newPushRequest(t)
+	req.Proto = "HTTP/1.0"
+	req.ProtoMajor = 1
+	req.ProtoMinor = 0
+
+	w := httptest.NewRecorder()
+
+	if err := h.ServeHTTP(w, req, noopNext); err != nil {
+		t.Fatalf("ServeHTTP returned error: %v", err)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected no interim response to be written over HTTP/1.0, got code %d", w.Code)
+	}
+	if len(w.Header()["Link"]) != 0 {
+		t.Errorf("expected no Link headers over HTTP/1.0, got %v", w.Header()["Link"])
+	}
+}
+
+func TestEarlyHintsMirrorRequestLinks(t *testing.T) {
+	h := EarlyHints{
+		Resources:          []EarlyHintResource{{Target: "/app.css"}},
+		MirrorRequestLinks: true,
+		logger:             zap.NewNop(),
+	}
+
+	req := newPushRequest(t)
+	ctx := context.WithValue(req.Context(), caddyhttp.VarsCtxKey, make(map[string]interface{}))
+	req = req.WithContext(ctx)
+	caddyhttp.SetVar(req.Context(), upstreamLink1xxVar, []string{"</from-upstream.js>; rel=preload"})
+
+	w := httptest.NewRecorder()
+
+	if err := h.ServeHTTP(w, req, noopNext); err != nil {
+		t.Fatalf("ServeHTTP returned error: %v", err)
+	}
+
+	links := w.Header()["Link"]
+	want := []string{
+		"</app.css>; rel=preload",
+		"</from-upstream.js>; rel=preload",
+	}
+	if len(links) != len(want) {
+		t.Fatalf("expected %d Link headers, got %v", len(want), links)
+	}
+	for i, link := range want {
+		if links[i] != link {
+			t.Errorf("Link header %d = %q, want %q", i, links[i], link)
+		}
+	}
+}
+
+func TestEarlyHintsMirrorRequestLinksOffByDefault(t *testing.T) {
+	h := EarlyHints{
+		Resources: []EarlyHintResource{{Target: "/app.css"}},
+		logger:    zap.NewNop(),
+	}
+
+	req := newPushRequest(t)
+	ctx := context.WithValue(req.Context(), caddyhttp.VarsCtxKey, make(map[string]interface{}))
+	req = req.WithContext(ctx)
+	caddyhttp.SetVar(req.Context(), upstreamLink1xxVar, []string{"</from-upstream.js>; rel=preload"})
+
+	w := httptest.NewRecorder()
+
+	if err := h.ServeHTTP(w, req, noopNext); err != nil {
+		t.Fatalf("ServeHTTP returned error: %v", err)
+	}
+
+	links := w.Header()["Link"]
+	if len(links) != 1 || links[0] != "</app.css>; rel=preload" {
+		t.Errorf("expected only the configured resource without MirrorRequestLinks, got %v", links)
+	}
+}
@@ -15,6 +15,8 @@
 package push
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
 	"net/http"
 	"strings"
@@ -34,6 +36,24 @@ type Handler struct {
 	Resources []Resource         `json:"resources,omitempty"`
 	Headers   *headers.HeaderOps `json:"headers,omitempty"`
 
+	// CacheDigest, if true, causes this handler to parse an incoming
+	// Cache-Digest request header and skip pushing any resource that
+	// the digest indicates the client already has cached. See the
+	// (now-expired) draft-ietf-httpbis-cache-digest. Since that draft
+	// never reached interoperability testing, this only works reliably
+	// when the digest was produced by something using the same
+	// fingerprint derivation as this package (see fingerprint in
+	// digest.go); it's best-effort against arbitrary clients.
+	CacheDigest bool `json:"cache_digest,omitempty"`
+
+	// PushCookie names a cookie that this handler sets after pushing
+	// resources, and consults on later requests to avoid pushing the
+	// same resources again. It's a coarser, best-effort fallback for
+	// clients that don't send a Cache-Digest header. If CacheDigest is
+	// also enabled and the request carries a valid digest, the digest
+	// takes precedence and this cookie is not consulted.
+	PushCookie string `json:"push_cookie,omitempty"`
+
 	logger *zap.Logger
 }
 
@@ -73,21 +93,66 @@ func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhtt
 	// create header for push requests
 	hdr := h.initializePushHeaders(r, repl)
 
+	// a cache digest, when present and valid, takes precedence over the
+	// cookie fallback for deciding which resources the client already has
+	var digest *cacheDigest
+	if h.CacheDigest {
+		if val := r.Header.Get("Cache-Digest"); val != "" {
+			d, err := parseCacheDigest(val)
+			if err != nil {
+				h.logger.Debug("invalid cache digest header", zap.Error(err))
+			} else {
+				digest = d
+			}
+		}
+	}
+
+	// fall back to the cookie marker only if we have no usable digest
+	skipAll := false
+	cookieValue := ""
+	if h.PushCookie != "" && digest == nil {
+		cookieValue = h.pushCookieValue(repl)
+		if c, err := r.Cookie(h.PushCookie); err == nil && c.Value == cookieValue {
+			skipAll = true
+		}
+	}
+
 	// push first!
-	for _, resource := range h.Resources {
-		h.logger.Debug("pushing resource",
-			zap.String("uri", r.RequestURI),
-			zap.String("push_method", resource.Method),
-			zap.String("push_target", resource.Target),
-			zap.Object("push_headers", caddyhttp.LoggableHTTPHeader(hdr)))
-		err := pusher.Push(repl.ReplaceAll(resource.Target, "."), &http.PushOptions{
-			Method: resource.Method,
-			Header: hdr,
-		})
-		if err != nil {
-			// usually this means either that push is not
-			// supported or concurrent streams are full
-			break
+	if !skipAll {
+		pushErr := error(nil)
+		for _, resource := range h.Resources {
+			target := repl.ReplaceAll(resource.Target, ".")
+			if digest != nil && digest.has(target) {
+				h.logger.Debug("skipping push; client cache digest indicates resource is cached",
+					zap.String("push_target", target))
+				continue
+			}
+			h.logger.Debug("pushing resource",
+				zap.String("uri", r.RequestURI),
+				zap.String("push_method", resource.Method),
+				zap.String("push_target", target),
+				zap.Object("push_headers", caddyhttp.LoggableHTTPHeader(hdr)))
+			pushErr = pusher.Push(target, &http.PushOptions{
+				Method: resource.Method,
+				Header: hdr,
+			})
+			if pushErr != nil {
+				// usually this means either that push is not
+				// supported or concurrent streams are full
+				break
+			}
+		}
+		// only remember this as "pushed" if every resource was either
+		// pushed or skipped on purpose; a resource the client never
+		// actually received (because the loop broke early on a push
+		// error) must not be marked as delivered, or it would be
+		// silently skipped forever afterward
+		if h.PushCookie != "" && digest == nil && pushErr == nil {
+			http.SetCookie(w, &http.Cookie{
+				Name:  h.PushCookie,
+				Value: cookieValue,
+				Path:  "/",
+			})
 		}
 	}
 
@@ -97,9 +162,19 @@ func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhtt
 	}
 
 	// finally, push any resources described by Link fields that were
-	// written to the response header, only if another push handler
-	// hasn't already done so
-	if links, ok := w.Header()["Link"]; ok {
+	// written to the response header, or surfaced by reverse_proxy from
+	// a 103 Early Hints interim response sent by the upstream (see
+	// upstreamLink1xxVar), only if another push handler hasn't already
+	// done so
+	// copy before appending: links is about to be extended, and
+	// w.Header()["Link"] is a slice we don't own, so appending directly
+	// to it risks clobbering the response header's backing array if it
+	// has spare capacity
+	links := append([]string(nil), w.Header()["Link"]...)
+	if upstream, ok := caddyhttp.GetVar(r.Context(), upstreamLink1xxVar).([]string); ok {
+		links = append(links, upstream...)
+	}
+	if len(links) > 0 {
 		if val := caddyhttp.GetVar(r.Context(), pushedLink); val == nil {
 			h.logger.Debug("pushing Link resources", zap.Strings("linked", links))
 			caddyhttp.SetVar(r.Context(), pushedLink, true)
@@ -146,6 +221,18 @@ func (h Handler) initializePushHeaders(r *http.Request, repl *caddy.Replacer) ht
 	return hdr
 }
 
+// pushCookieValue computes the value used for h.PushCookie: a digest of
+// the configured resources, so that a change to the push list naturally
+// invalidates any cookie a client already carries.
+func (h Handler) pushCookieValue(repl *caddy.Replacer) string {
+	targets := make([]string, len(h.Resources))
+	for i, resource := range h.Resources {
+		targets[i] = repl.ReplaceAll(resource.Target, ".")
+	}
+	sum := sha1.Sum([]byte(strings.Join(targets, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
 // servePreloadLinks parses Link headers from upstream and pushes
 // resources described by them. If a resource has the "nopush"
 // attribute or describes an external entity (meaning, the resource
@@ -201,6 +288,14 @@ const pushHeader = "X-Caddy-Push"
 // pushing of Link headers.
 const pushedLink = "http.handlers.push.pushed_link"
 
+// upstreamLink1xxVar mirrors the caddyhttp variable key of the same
+// name that reverseproxy sets when the upstream sends Link values in a
+// 1xx (Early Hints) interim response; see upstreamLink1xxVar in
+// reverseproxy's upstream_hints.go. It's duplicated here as a plain
+// string, rather than imported, to avoid a package dependency on
+// reverseproxy for a single shared constant.
+const upstreamLink1xxVar = "reverse_proxy.upstream_1xx_link"
+
 // Interface guards
 var (
 	_ caddy.Provisioner           = (*Handler)(nil)
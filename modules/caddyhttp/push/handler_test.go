@@ -0,0 +1,165 @@
+// Copyright 2015 Matthew Holt and The Caddy Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package push
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap"
+)
+
+// fakePusherRecorder is an httptest.ResponseRecorder that also
+// implements http.Pusher, recording every pushed target instead of
+// actually pushing anything. If failAfter is non-negative, the
+// failAfter'th call to Push (0-indexed) returns errPushFailed instead
+// of succeeding, to simulate a concurrent-stream-limit-style failure.
+type fakePusherRecorder struct {
+	*httptest.ResponseRecorder
+	pushed    []string
+	failAfter int
+}
+
+var errPushFailed = errors.New("push: concurrent streams full")
+
+func (f *fakePusherRecorder) Push(target string, opts *http.PushOptions) error {
+	if f.failAfter >= 0 && len(f.pushed) >= f.failAfter {
+		return errPushFailed
+	}
+	f.pushed = append(f.pushed, target)
+	return nil
+}
+
+func newPushRequest(t *testing.T) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	ctx := context.WithValue(req.Context(), caddy.ReplacerCtxKey, caddy.NewReplacer())
+	return req.WithContext(ctx)
+}
+
+var noopNext = caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+	return nil
+})
+
+func TestServeHTTPCacheDigestTakesPrecedenceOverCookie(t *testing.T) {
+	h := Handler{
+		Resources: []Resource{
+			{Target: "/a.css"},
+			{Target: "/b.css"},
+		},
+		CacheDigest: true,
+		PushCookie:  "_pushed",
+		logger:      zap.NewNop(),
+	}
+
+	req := newPushRequest(t)
+	repl := req.Context().Value(caddy.ReplacerCtxKey).(*caddy.Replacer)
+
+	// the digest only covers /a.css
+	digestValue := encodeCacheDigest(t, 12, []uint64{fingerprint("/a.css") % (1 << 12)})
+	req.Header.Set("Cache-Digest", digestValue)
+
+	// the cookie, if it were consulted, would cause every resource to
+	// be skipped -- it must be ignored because a valid digest is present
+	req.AddCookie(&http.Cookie{Name: "_pushed", Value: h.pushCookieValue(repl)})
+
+	w := &fakePusherRecorder{ResponseRecorder: httptest.NewRecorder(), failAfter: -1}
+
+	if err := h.ServeHTTP(w, req, noopNext); err != nil {
+		t.Fatalf("ServeHTTP returned error: %v", err)
+	}
+
+	if len(w.pushed) != 1 || w.pushed[0] != "/b.css" {
+		t.Errorf("expected only /b.css to be pushed, got %v", w.pushed)
+	}
+}
+
+func TestServeHTTPCookieSkipsAllWhenNoDigest(t *testing.T) {
+	h := Handler{
+		Resources: []Resource{
+			{Target: "/a.css"},
+			{Target: "/b.css"},
+		},
+		CacheDigest: true, // enabled, but request carries no digest header
+		PushCookie:  "_pushed",
+		logger:      zap.NewNop(),
+	}
+
+	req := newPushRequest(t)
+	repl := req.Context().Value(caddy.ReplacerCtxKey).(*caddy.Replacer)
+	req.AddCookie(&http.Cookie{Name: "_pushed", Value: h.pushCookieValue(repl)})
+
+	w := &fakePusherRecorder{ResponseRecorder: httptest.NewRecorder(), failAfter: -1}
+
+	if err := h.ServeHTTP(w, req, noopNext); err != nil {
+		t.Fatalf("ServeHTTP returned error: %v", err)
+	}
+
+	if len(w.pushed) != 0 {
+		t.Errorf("expected no pushes with a matching cookie and no digest, got %v", w.pushed)
+	}
+}
+
+func TestServeHTTPPushesEverythingWithoutDigestOrCookie(t *testing.T) {
+	h := Handler{
+		Resources: []Resource{
+			{Target: "/a.css"},
+			{Target: "/b.css"},
+		},
+		logger: zap.NewNop(),
+	}
+
+	req := newPushRequest(t)
+	w := &fakePusherRecorder{ResponseRecorder: httptest.NewRecorder(), failAfter: -1}
+
+	if err := h.ServeHTTP(w, req, noopNext); err != nil {
+		t.Fatalf("ServeHTTP returned error: %v", err)
+	}
+
+	if len(w.pushed) != 2 {
+		t.Errorf("expected both resources to be pushed, got %v", w.pushed)
+	}
+}
+
+func TestServeHTTPDoesNotSetCookieOnPartialPush(t *testing.T) {
+	h := Handler{
+		Resources: []Resource{
+			{Target: "/a.css"},
+			{Target: "/b.css"},
+		},
+		PushCookie: "_pushed",
+		logger:     zap.NewNop(),
+	}
+
+	req := newPushRequest(t)
+	// the first push fails, so the loop breaks before reaching /b.css
+	w := &fakePusherRecorder{ResponseRecorder: httptest.NewRecorder(), failAfter: 0}
+
+	if err := h.ServeHTTP(w, req, noopNext); err != nil {
+		t.Fatalf("ServeHTTP returned error: %v", err)
+	}
+
+	if len(w.pushed) != 0 {
+		t.Errorf("expected no successful pushes, got %v", w.pushed)
+	}
+	if c := w.Result().Cookies(); len(c) != 0 {
+		t.Errorf("expected no cookie to be set after a partial push, got %v", c)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2015 Matthew Holt and The Caddy Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reverseproxy
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap"
+)
+
+func init() {
+	caddy.RegisterModule(Handler{})
+}
+
+// Handler proxies requests to a single upstream over HTTP.
+type Handler struct {
+	// Upstream is the dial address of the backend to proxy requests to,
+	// e.g. "localhost:8080".
+	Upstream string `json:"upstream,omitempty"`
+
+	transport http.RoundTripper
+	logger    *zap.Logger
+}
+
+// CaddyModule returns the Caddy module information.
+func (Handler) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.reverse_proxy",
+		New: func() caddy.Module { return new(Handler) },
+	}
+}
+
+// Provision sets up h.
+func (h *Handler) Provision(ctx caddy.Context) error {
+	h.logger = ctx.Logger(h)
+	if h.transport == nil {
+		h.transport = http.DefaultTransport
+	}
+	return nil
+}
+
+func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
+	outreq := r.Clone(r.Context())
+	outreq.RequestURI = ""
+	outreq.URL.Scheme = "http"
+	outreq.URL.Host = h.Upstream
+
+	// instrument the outbound request so that any Link values the
+	// upstream sends on a 103 Early Hints interim response get recorded
+	// on the original request's context, where handlers further up the
+	// chain (such as push) can retrieve them after we return; see
+	// withUpstream1xxLinkCapture in upstream_hints.go.
+	outreq = withUpstream1xxLinkCapture(outreq)
+
+	resp, err := h.transport.RoundTrip(outreq)
+	if err != nil {
+		h.logger.Error("upstream round trip failed", zap.Error(err))
+		return err
+	}
+	defer resp.Body.Close()
+
+	for field, vals := range resp.Header {
+		w.Header()[field] = vals
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// Interface guards
+var (
+	_ caddy.Provisioner           = (*Handler)(nil)
+	_ caddyhttp.MiddlewareHandler = (*Handler)(nil)
+)
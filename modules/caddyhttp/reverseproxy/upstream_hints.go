@@ -0,0 +1,67 @@
+// Copyright 2015 Matthew Holt and The Caddy Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reverseproxy
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+// upstreamLink1xxVar is the key of a caddyhttp variable that this
+// package sets on the original downstream request whenever the
+// upstream sends one or more Link values in a 103 Early Hints interim
+// response while we're still waiting on its final response; Link values
+// on other 1xx responses are not recorded. Unlike the final response, 1xx headers are never written
+// to the downstream http.ResponseWriter, so any handler further up the
+// chain that wants to react to upstream early hints (for example, push,
+// to translate them into HTTP/2 pushes or its own 103 response) has to
+// read this variable instead of w.Header() or r.Header.
+//
+// This is kept as a plain, documented string rather than an exported
+// identifier so that consumers don't need to import this package just
+// to depend on the contract.
+const upstreamLink1xxVar = "reverse_proxy.upstream_1xx_link"
+
+// withUpstream1xxLinkCapture returns a shallow copy of r whose context
+// is instrumented to capture Link header values from any 103 Early
+// Hints interim response the upstream sends during this round trip. Captured values
+// are recorded under upstreamLink1xxVar on r's own context (which
+// outlives the round trip), so handlers running after this request
+// continues down the chain can retrieve them with
+// caddyhttp.GetVar(r.Context(), ...).
+//
+// Handler.ServeHTTP (reverseproxy.go) routes the outbound request
+// through this before calling RoundTrip, so that Got1xxResponse
+// actually fires for this request.
+func withUpstream1xxLinkCapture(r *http.Request) *http.Request {
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			if code != http.StatusEarlyHints {
+				return nil
+			}
+			links := header["Link"]
+			if len(links) == 0 {
+				return nil
+			}
+			existing, _ := caddyhttp.GetVar(r.Context(), upstreamLink1xxVar).([]string)
+			caddyhttp.SetVar(r.Context(), upstreamLink1xxVar, append(existing, links...))
+			return nil
+		},
+	}
+	return r.WithContext(httptrace.WithClientTrace(r.Context(), trace))
+}